@@ -0,0 +1,26 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+// Package cache 定义了一个通用的键值缓存接口, 用来在多进程/多实例之间共享
+// access_token, jsapi_ticket 等有效期较短的数据, 避免各个实例各自刷新互相抢占配额.
+package cache
+
+import "time"
+
+// Cache 是缓存后端需要实现的接口.
+//  实现者必须是并发安全的.
+type Cache interface {
+	// Get 返回 key 对应的值, 如果不存在或者已经过期则返回 nil.
+	Get(key string) interface{}
+
+	// Set 设置 key 对应的值, ttl <= 0 表示永不过期.
+	Set(key string, val interface{}, ttl time.Duration) error
+
+	// IsExist 判断 key 是否存在且没有过期.
+	IsExist(key string) bool
+
+	// Delete 删除 key.
+	Delete(key string) error
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MemcacheCache 是 Cache 接口基于 Memcache 的实现.
+//  值用 encoding/gob 序列化之后存储, 所以 val 的具体类型需要提前用 gob.Register 注册
+//  (基本类型, 如 string, 不需要注册).
+type MemcacheCache struct {
+	client *memcache.Client
+}
+
+// NewMemcacheCache 创建一个 MemcacheCache, client 由调用者负责创建.
+func NewMemcacheCache(client *memcache.Client) *MemcacheCache {
+	return &MemcacheCache{client: client}
+}
+
+func (c *MemcacheCache) Get(key string) interface{} {
+	item, err := c.client.Get(key)
+	if err != nil {
+		return nil
+	}
+
+	var val interface{}
+	if err = gob.NewDecoder(bytes.NewReader(item.Value)).Decode(&val); err != nil {
+		return nil
+	}
+	return val
+}
+
+func (c *MemcacheCache) Set(key string, val interface{}, ttl time.Duration) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&val); err != nil {
+		return err
+	}
+	expiration := int32(ttl / time.Second)
+	if expiration < 0 {
+		expiration = 0 // memcached 把负数当作立即过期, 而 Cache 约定 ttl <= 0 表示永不过期
+	}
+	return c.client.Set(&memcache.Item{
+		Key:        key,
+		Value:      buf.Bytes(),
+		Expiration: expiration,
+	})
+}
+
+func (c *MemcacheCache) IsExist(key string) bool {
+	_, err := c.client.Get(key)
+	return err == nil
+}
+
+func (c *MemcacheCache) Delete(key string) error {
+	err := c.client.Delete(key)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
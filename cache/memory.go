@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryItem 是 MemoryCache 内部存储的一条记录.
+type memoryItem struct {
+	val       interface{}
+	expiresAt time.Time // 零值表示永不过期
+}
+
+func (item *memoryItem) expired() bool {
+	return !item.expiresAt.IsZero() && time.Now().After(item.expiresAt)
+}
+
+// MemoryCache 是 Cache 接口的进程内实现, 适合单进程部署或者测试场景.
+type MemoryCache struct {
+	mu    sync.RWMutex
+	items map[string]*memoryItem
+}
+
+// NewMemoryCache 创建一个 MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		items: make(map[string]*memoryItem),
+	}
+}
+
+func (c *MemoryCache) Get(key string) interface{} {
+	c.mu.RLock()
+	item, ok := c.items[key]
+	c.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if item.expired() {
+		c.Delete(key)
+		return nil
+	}
+	return item.val
+}
+
+func (c *MemoryCache) Set(key string, val interface{}, ttl time.Duration) error {
+	item := &memoryItem{val: val}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	c.items[key] = item
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *MemoryCache) IsExist(key string) bool {
+	return c.Get(key) != nil
+}
+
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.mu.Unlock()
+	return nil
+}
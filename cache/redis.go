@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisCache 是 Cache 接口基于 Redis 的实现, 用于多进程/多机器共享缓存.
+//  值用 encoding/gob 序列化之后存储, 所以 val 的具体类型需要提前用 gob.Register 注册
+//  (基本类型, 如 string, 不需要注册).
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache 创建一个 RedisCache, client 由调用者负责创建和关闭.
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (c *RedisCache) Get(key string) interface{} {
+	data, err := c.client.Get(key).Bytes()
+	if err != nil {
+		return nil
+	}
+
+	var val interface{}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&val); err != nil {
+		return nil
+	}
+	return val
+}
+
+func (c *RedisCache) Set(key string, val interface{}, ttl time.Duration) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&val); err != nil {
+		return err
+	}
+	return c.client.Set(key, buf.Bytes(), ttl).Err()
+}
+
+func (c *RedisCache) IsExist(key string) bool {
+	n, err := c.client.Exists(key).Result()
+	return err == nil && n > 0
+}
+
+func (c *RedisCache) Delete(key string) error {
+	return c.client.Del(key).Err()
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chanxuehong/wechat/cache"
+)
+
+// accessTokenCacheKeyPrefix 下面拼上 appId 就是该公众号 access_token 在 Cache 里的 key.
+const accessTokenCacheKeyPrefix = "wechat.access_token."
+
+// accessTokenExpiresInGap 是考虑到网络延时和时钟误差, 提前让 access_token 过期的秒数.
+const accessTokenExpiresInGap = 10 * time.Minute
+
+// AccessTokenServer 负责获取和刷新 access_token.
+//  多个进程/协程如果共用同一个 cache.Cache 后端(比如 Redis), 就可以共享同一份 access_token,
+//  避免每个实例各自刷新而抢占 2 小时的调用配额.
+type AccessTokenServer struct {
+	appId     string
+	appSecret string
+	cache     cache.Cache
+}
+
+// NewAccessTokenServer 创建一个 AccessTokenServer.
+//  cache 为 nil 时退化为每次都重新向微信服务器申请 access_token, 不做任何缓存.
+func NewAccessTokenServer(appId, appSecret string, c cache.Cache) *AccessTokenServer {
+	return &AccessTokenServer{
+		appId:     appId,
+		appSecret: appSecret,
+		cache:     c,
+	}
+}
+
+// Token 返回一个有效的 access_token, 优先从缓存读取, 缓存未命中时向微信服务器申请并刷新缓存.
+func (srv *AccessTokenServer) Token() (token string, err error) {
+	key := accessTokenCacheKeyPrefix + srv.appId
+
+	if srv.cache != nil {
+		if val := srv.cache.Get(key); val != nil {
+			if token, ok := val.(string); ok {
+				return token, nil
+			}
+		}
+	}
+
+	token, expiresIn, err := srv.refreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	if srv.cache != nil {
+		ttl := time.Duration(expiresIn)*time.Second - accessTokenExpiresInGap
+		if ttl > 0 {
+			srv.cache.Set(key, token, ttl)
+		}
+	}
+	return token, nil
+}
+
+// accessTokenResponse 是 https://api.weixin.qq.com/cgi-bin/token 的响应.
+type accessTokenResponse struct {
+	Error
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// refreshToken 无条件地向微信服务器申请一个新的 access_token.
+func (srv *AccessTokenServer) refreshToken() (token string, expiresIn int64, err error) {
+	url := fmt.Sprintf(
+		"https://api.weixin.qq.com/cgi-bin/token?grant_type=client_credential&appid=%s&secret=%s",
+		srv.appId, srv.appSecret,
+	)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	var result accessTokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, err
+	}
+	if result.ErrCode != 0 {
+		return "", 0, &result.Error
+	}
+	return result.AccessToken, result.ExpiresIn, nil
+}
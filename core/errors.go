@@ -0,0 +1,20 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+// Package core 提供了 access_token, jsapi_ticket 这类有效期短/需要跨进程共享的
+// 凭证的获取和自动刷新逻辑, 底层通过 cache.Cache 做缓存, 多个进程/实例可以共用同一份凭证.
+package core
+
+import "fmt"
+
+// Error 对应微信接口返回的 {"errcode":..., "errmsg":...} 错误.
+type Error struct {
+	ErrCode int64  `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("errcode: %d, errmsg: %s", e.ErrCode, e.ErrMsg)
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chanxuehong/wechat/cache"
+)
+
+// jsapiTicketCacheKeyPrefix 下面拼上 appId 就是该公众号 jsapi_ticket 在 Cache 里的 key.
+const jsapiTicketCacheKeyPrefix = "wechat.jsapi_ticket."
+
+// jsapiTicketExpiresInGap 是考虑到网络延时和时钟误差, 提前让 jsapi_ticket 过期的秒数.
+const jsapiTicketExpiresInGap = 10 * time.Minute
+
+// JSAPITicketServer 负责获取和刷新 jsapi_ticket.
+//  跟 AccessTokenServer 一样, 共用同一个 cache.Cache 后端就可以在多进程间共享 jsapi_ticket.
+type JSAPITicketServer struct {
+	appId             string
+	cache             cache.Cache
+	accessTokenServer *AccessTokenServer
+}
+
+// NewJSAPITicketServer 创建一个 JSAPITicketServer.
+//  cache 为 nil 时退化为每次都重新向微信服务器申请 jsapi_ticket, 不做任何缓存.
+func NewJSAPITicketServer(appId string, c cache.Cache, accessTokenServer *AccessTokenServer) *JSAPITicketServer {
+	return &JSAPITicketServer{
+		appId:             appId,
+		cache:             c,
+		accessTokenServer: accessTokenServer,
+	}
+}
+
+// Ticket 返回一个有效的 jsapi_ticket, 优先从缓存读取, 缓存未命中时向微信服务器申请并刷新缓存.
+func (srv *JSAPITicketServer) Ticket() (ticket string, err error) {
+	key := jsapiTicketCacheKeyPrefix + srv.appId
+
+	if srv.cache != nil {
+		if val := srv.cache.Get(key); val != nil {
+			if ticket, ok := val.(string); ok {
+				return ticket, nil
+			}
+		}
+	}
+
+	ticket, expiresIn, err := srv.refreshTicket()
+	if err != nil {
+		return "", err
+	}
+
+	if srv.cache != nil {
+		ttl := time.Duration(expiresIn)*time.Second - jsapiTicketExpiresInGap
+		if ttl > 0 {
+			srv.cache.Set(key, ticket, ttl)
+		}
+	}
+	return ticket, nil
+}
+
+// jsapiTicketResponse 是 https://api.weixin.qq.com/cgi-bin/ticket/getticket 的响应.
+type jsapiTicketResponse struct {
+	Error
+	Ticket    string `json:"ticket"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// refreshTicket 无条件地向微信服务器申请一个新的 jsapi_ticket.
+func (srv *JSAPITicketServer) refreshTicket() (ticket string, expiresIn int64, err error) {
+	accessToken, err := srv.accessTokenServer.Token()
+	if err != nil {
+		return "", 0, err
+	}
+
+	url := fmt.Sprintf(
+		"https://api.weixin.qq.com/cgi-bin/ticket/getticket?type=jsapi&access_token=%s",
+		accessToken,
+	)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	var result jsapiTicketResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, err
+	}
+	if result.ErrCode != 0 {
+		return "", 0, &result.Error
+	}
+	return result.Ticket, result.ExpiresIn, nil
+}
@@ -0,0 +1,186 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+// Package message 定义了被动回复消息(Reply)的数据结构.
+//  跟 message/request 包的消息不一样, 这里的消息是开发者主动构造, 回复给微信服务器的.
+package message
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reply 是一条待回复的被动消息, 它的 xml 信封(ToUserName/FromUserName/CreateTime)
+// 由 server.Handler 在回复的时候统一填写, 调用者只需要关心消息本身的内容.
+type Reply struct {
+	MsgType string
+	MsgData fragmenter
+}
+
+// fragmenter 知道怎么把自己序列化成被动回复 xml 里 MsgType 之后的那部分内容.
+type fragmenter interface {
+	XMLFragment() string
+}
+
+// cdata 把 s 包装成一个 CDATA 段落, 返回值里已经带着 <![CDATA[ ]]>.
+//  CDATA 段落本身不能包含字面的 "]]>", 所以如果 s 里有这个序列, 需要在那里结束当前
+//  CDATA 段落再开一个新的("]]" + "]]><![CDATA[" + ">"), 这是 CDATA 转义的标准做法.
+func cdata(s string) string {
+	return "<![CDATA[" + strings.Replace(s, "]]>", "]]]]><![CDATA[>", -1) + "]]>"
+}
+
+// Text 文本消息.
+type Text struct {
+	Content string
+}
+
+func (m *Text) XMLFragment() string {
+	return fmt.Sprintf(`<Content>%s</Content>`, cdata(m.Content))
+}
+
+// NewText 创建一条文本回复消息.
+func NewText(content string) *Reply {
+	return &Reply{MsgType: "text", MsgData: &Text{Content: content}}
+}
+
+// Image 图片消息.
+type Image struct {
+	MediaId string
+}
+
+func (m *Image) XMLFragment() string {
+	return fmt.Sprintf(`<Image><MediaId>%s</MediaId></Image>`, cdata(m.MediaId))
+}
+
+// NewImage 创建一条图片回复消息.
+func NewImage(mediaId string) *Reply {
+	return &Reply{MsgType: "image", MsgData: &Image{MediaId: mediaId}}
+}
+
+// Voice 语音消息.
+type Voice struct {
+	MediaId string
+}
+
+func (m *Voice) XMLFragment() string {
+	return fmt.Sprintf(`<Voice><MediaId>%s</MediaId></Voice>`, cdata(m.MediaId))
+}
+
+// NewVoice 创建一条语音回复消息.
+func NewVoice(mediaId string) *Reply {
+	return &Reply{MsgType: "voice", MsgData: &Voice{MediaId: mediaId}}
+}
+
+// Video 视频消息.
+type Video struct {
+	MediaId     string
+	Title       string
+	Description string
+}
+
+func (m *Video) XMLFragment() string {
+	return fmt.Sprintf(
+		`<Video><MediaId>%s</MediaId><Title>%s</Title>`+
+			`<Description>%s</Description></Video>`,
+		cdata(m.MediaId), cdata(m.Title), cdata(m.Description),
+	)
+}
+
+// NewVideo 创建一条视频回复消息.
+func NewVideo(mediaId, title, description string) *Reply {
+	return &Reply{MsgType: "video", MsgData: &Video{
+		MediaId:     mediaId,
+		Title:       title,
+		Description: description,
+	}}
+}
+
+// Music 音乐消息.
+type Music struct {
+	Title        string
+	Description  string
+	MusicURL     string
+	HQMusicURL   string
+	ThumbMediaId string
+}
+
+func (m *Music) XMLFragment() string {
+	return fmt.Sprintf(
+		`<Music><Title>%s</Title><Description>%s</Description>`+
+			`<MusicUrl>%s</MusicUrl><HQMusicUrl>%s</HQMusicUrl>`+
+			`<ThumbMediaId>%s</ThumbMediaId></Music>`,
+		cdata(m.Title), cdata(m.Description), cdata(m.MusicURL), cdata(m.HQMusicURL), cdata(m.ThumbMediaId),
+	)
+}
+
+// NewMusic 创建一条音乐回复消息.
+func NewMusic(title, description, musicURL, hqMusicURL, thumbMediaId string) *Reply {
+	return &Reply{MsgType: "music", MsgData: &Music{
+		Title:        title,
+		Description:  description,
+		MusicURL:     musicURL,
+		HQMusicURL:   hqMusicURL,
+		ThumbMediaId: thumbMediaId,
+	}}
+}
+
+// Article 是图文消息里的一条文章.
+type Article struct {
+	Title       string
+	Description string
+	PicURL      string
+	URL         string
+}
+
+// News 图文消息, 最多 10 条 Article.
+type News struct {
+	Articles []Article
+}
+
+func (m *News) XMLFragment() string {
+	items := ""
+	for _, a := range m.Articles {
+		items += fmt.Sprintf(
+			`<item><Title>%s</Title><Description>%s</Description>`+
+				`<PicUrl>%s</PicUrl><Url>%s</Url></item>`,
+			cdata(a.Title), cdata(a.Description), cdata(a.PicURL), cdata(a.URL),
+		)
+	}
+	return fmt.Sprintf(
+		`<ArticleCount>%d</ArticleCount><Articles>%s</Articles>`,
+		len(m.Articles), items,
+	)
+}
+
+// NewNews 创建一条图文回复消息.
+func NewNews(articles []Article) *Reply {
+	return &Reply{MsgType: "news", MsgData: &News{Articles: articles}}
+}
+
+// TransferCustomerService 把当前会话转接给人工客服.
+//  KfAccount 为空表示转给任意在线客服, 非空表示转给指定的客服账号.
+type TransferCustomerService struct {
+	KfAccount string
+}
+
+func (m *TransferCustomerService) XMLFragment() string {
+	if m.KfAccount == "" {
+		return ""
+	}
+	return fmt.Sprintf(
+		`<TransInfo><KfAccount>%s</KfAccount></TransInfo>`,
+		cdata(m.KfAccount),
+	)
+}
+
+// NewTransferCustomer 创建一条转人工客服的回复消息, kfAccount 可选, 留空表示
+// 转给任意在线客服.
+func NewTransferCustomer(kfAccount ...string) *Reply {
+	msg := &TransferCustomerService{}
+	if len(kfAccount) > 0 {
+		msg.KfAccount = kfAccount[0]
+	}
+	return &Reply{MsgType: "transfer_customer_service", MsgData: msg}
+}
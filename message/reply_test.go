@@ -0,0 +1,30 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCDATAEscapesClosingSequence 验证 cdata() 在内容包含字面的 "]]>" 时会正确地
+// 结束当前 CDATA 段落并重新打开一个新的, 而不是直接把 "]]>" 原样拼进去提前截断 CDATA.
+func TestCDATAEscapesClosingSequence(t *testing.T) {
+	got := cdata("hello ]]> world")
+	if strings.Contains(got, "]]> world") {
+		t.Fatalf("cdata() did not escape the literal \"]]>\": %q", got)
+	}
+	want := "<![CDATA[hello ]]]]><![CDATA[> world]]>"
+	if got != want {
+		t.Errorf("cdata() = %q, want %q", got, want)
+	}
+}
+
+// TestTextXMLFragmentEscapesUserContent 验证一个带有 "]]>" 的用户回复文本仍然能
+// 生成结构合法的 <Content> CDATA 片段.
+func TestTextXMLFragmentEscapesUserContent(t *testing.T) {
+	text := &Text{Content: "a]]>b"}
+	got := text.XMLFragment()
+	want := "<Content><![CDATA[a]]]]><![CDATA[>b]]></Content>"
+	if got != want {
+		t.Errorf("XMLFragment() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,367 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+// Package request 定义了微信服务器推送过来的消息(事件)的数据结构.
+package request
+
+// 消息类型
+const (
+	MSG_TYPE_TEXT     = "text"
+	MSG_TYPE_IMAGE    = "image"
+	MSG_TYPE_VOICE    = "voice"
+	MSG_TYPE_VIDEO    = "video"
+	MSG_TYPE_LOCATION = "location"
+	MSG_TYPE_LINK     = "link"
+	MSG_TYPE_EVENT    = "event"
+)
+
+// 事件类型
+const (
+	EVENT_TYPE_SUBSCRIBE         = "subscribe"
+	EVENT_TYPE_UNSUBSCRIBE       = "unsubscribe"
+	EVENT_TYPE_SCAN              = "SCAN"
+	EVENT_TYPE_LOCATION          = "LOCATION"
+	EVENT_TYPE_CLICK             = "CLICK"
+	EVENT_TYPE_VIEW              = "VIEW"
+	EVENT_TYPE_MASSSENDJOBFINISH = "MASSSENDJOBFINISH"
+	EVENT_TYPE_MERCHANTORDER     = "merchant_order"
+
+	EVENT_TYPE_SCANCODEPUSH    = "scancode_push"
+	EVENT_TYPE_SCANCODEWAITMSG = "scancode_waitmsg"
+
+	EVENT_TYPE_PICSYSPHOTO     = "pic_sysphoto"
+	EVENT_TYPE_PICPHOTOORALBUM = "pic_photo_or_album"
+	EVENT_TYPE_PICWEIXIN       = "pic_weixin"
+
+	EVENT_TYPE_LOCATIONSELECT = "location_select"
+
+	EVENT_TYPE_TEMPLATESENDJOBFINISH = "TEMPLATESENDJOBFINISH"
+
+	EVENT_TYPE_QUALIFICATIONVERIFYSUCCESS = "qualification_verify_success"
+	EVENT_TYPE_QUALIFICATIONVERIFYFAIL    = "qualification_verify_fail"
+
+	EVENT_TYPE_CARDPASSCHECK = "card_pass_check"
+	EVENT_TYPE_USERGETCARD   = "user_get_card"
+	EVENT_TYPE_USERDELCARD   = "user_del_card"
+)
+
+// CommonHead 是所有消息(事件)共有的头部字段.
+type CommonHead struct {
+	ToUserName   string `xml:"ToUserName"   json:"ToUserName"`
+	FromUserName string `xml:"FromUserName" json:"FromUserName"`
+	CreateTime   int64  `xml:"CreateTime"   json:"CreateTime"`
+	MsgType      string `xml:"MsgType"      json:"MsgType"`
+}
+
+// Request 是从微信服务器推送过来的消息(事件)的"并集", 用于一次性 xml.Unmarshal,
+// 然后根据 MsgType, Event 字段路由到具体的消息(事件)类型.
+//  NOTE: 这个结构体字段比较多, 但是都是简单类型, 没有性能问题.
+type Request struct {
+	XMLName struct{} `xml:"xml" json:"-"`
+	CommonHead
+
+	MsgId int64 `xml:"MsgId" json:"MsgId"`
+
+	Content string `xml:"Content" json:"Content"` // request.MSG_TYPE_TEXT
+
+	MediaId      string `xml:"MediaId"      json:"MediaId"`      // request.MSG_TYPE_IMAGE, VOICE, VIDEO
+	PicURL       string `xml:"PicUrl"       json:"PicUrl"`       // request.MSG_TYPE_IMAGE
+	Format       string `xml:"Format"       json:"Format"`       // request.MSG_TYPE_VOICE
+	Recognition  string `xml:"Recognition"  json:"Recognition"`  // request.MSG_TYPE_VOICE
+	ThumbMediaId string `xml:"ThumbMediaId" json:"ThumbMediaId"` // request.MSG_TYPE_VIDEO
+
+	LocationX float64 `xml:"Location_X" json:"Location_X"` // request.MSG_TYPE_LOCATION
+	LocationY float64 `xml:"Location_Y" json:"Location_Y"` // request.MSG_TYPE_LOCATION
+	Scale     int64   `xml:"Scale"      json:"Scale"`      // request.MSG_TYPE_LOCATION
+	Label     string  `xml:"Label"      json:"Label"`      // request.MSG_TYPE_LOCATION
+
+	Title       string `xml:"Title"       json:"Title"`       // request.MSG_TYPE_LINK
+	Description string `xml:"Description" json:"Description"` // request.MSG_TYPE_LINK
+	URL         string `xml:"Url"         json:"Url"`         // request.MSG_TYPE_LINK
+
+	Event    string `xml:"Event"    json:"Event"`    // request.MSG_TYPE_EVENT
+	EventKey string `xml:"EventKey" json:"EventKey"` // EVENT_TYPE_CLICK, VIEW, SUBSCRIBE(scan), SCAN
+
+	Latitude  float64 `xml:"Latitude"  json:"Latitude"`  // EVENT_TYPE_LOCATION
+	Longitude float64 `xml:"Longitude" json:"Longitude"` // EVENT_TYPE_LOCATION
+	Precision float64 `xml:"Precision" json:"Precision"` // EVENT_TYPE_LOCATION
+
+	Ticket string `xml:"Ticket" json:"Ticket"` // EVENT_TYPE_SUBSCRIBE(scan), SCAN
+
+	OrderId     string `xml:"OrderId"     json:"OrderId"`     // EVENT_TYPE_MERCHANTORDER
+	OrderStatus int64  `xml:"OrderStatus" json:"OrderStatus"` // EVENT_TYPE_MERCHANTORDER
+	ProductId   string `xml:"ProductId"   json:"ProductId"`   // EVENT_TYPE_MERCHANTORDER
+	SkuInfo     string `xml:"SkuInfo"     json:"SkuInfo"`     // EVENT_TYPE_MERCHANTORDER
+
+	MsgID       int64  `xml:"MsgID"       json:"MsgID"`       // EVENT_TYPE_MASSSENDJOBFINISH, TEMPLATESENDJOBFINISH, NOTE 大小写特殊
+	Status      string `xml:"Status"      json:"Status"`      // EVENT_TYPE_MASSSENDJOBFINISH, TEMPLATESENDJOBFINISH
+	TotalCount  int64  `xml:"TotalCount"  json:"TotalCount"`  // EVENT_TYPE_MASSSENDJOBFINISH
+	FilterCount int64  `xml:"FilterCount" json:"FilterCount"` // EVENT_TYPE_MASSSENDJOBFINISH
+	SentCount   int64  `xml:"SentCount"   json:"SentCount"`   // EVENT_TYPE_MASSSENDJOBFINISH
+	ErrorCount  int64  `xml:"ErrorCount"  json:"ErrorCount"`  // EVENT_TYPE_MASSSENDJOBFINISH
+
+	ScanCodeInfo ScanCodeInfo `xml:"ScanCodeInfo"` // EVENT_TYPE_SCANCODEPUSH, SCANCODEWAITMSG
+
+	SendPicsInfo SendPicsInfo `xml:"SendPicsInfo"` // EVENT_TYPE_PICSYSPHOTO, PICPHOTOORALBUM, PICWEIXIN
+
+	SendLocationInfo SendLocationInfo `xml:"SendLocationInfo"` // EVENT_TYPE_LOCATIONSELECT
+
+	ExpiredTime int64  `xml:"ExpiredTime" json:"ExpiredTime"` // EVENT_TYPE_QUALIFICATIONVERIFYSUCCESS
+	FailTime    int64  `xml:"FailTime"    json:"FailTime"`    // EVENT_TYPE_QUALIFICATIONVERIFYFAIL
+	FailReason  string `xml:"FailReason"  json:"FailReason"`  // EVENT_TYPE_QUALIFICATIONVERIFYFAIL
+
+	CardId       string `xml:"CardId"       json:"CardId"`       // EVENT_TYPE_CARDPASSCHECK, USERGETCARD, USERDELCARD
+	RefuseReason string `xml:"RefuseReason" json:"RefuseReason"` // EVENT_TYPE_CARDPASSCHECK
+	UserCardCode string `xml:"UserCardCode" json:"UserCardCode"` // EVENT_TYPE_USERGETCARD, USERDELCARD
+}
+
+// ScanCodeInfo 是扫码事件携带的扫码信息.
+type ScanCodeInfo struct {
+	ScanType   string `xml:"ScanType"   json:"ScanType"`
+	ScanResult string `xml:"ScanResult" json:"ScanResult"`
+}
+
+// PicItem 是 SendPicsInfo 里的一张图片.
+type PicItem struct {
+	PicMd5Sum string `xml:"PicMd5Sum" json:"PicMd5Sum"`
+}
+
+// SendPicsInfo 是弹出拍照/相册/微信相册发图器事件携带的图片信息.
+type SendPicsInfo struct {
+	Count   int64     `xml:"Count"                json:"Count"`
+	PicList []PicItem `xml:"PicList>item" json:"PicList"`
+}
+
+// SendLocationInfo 是弹出地理位置选择器事件携带的位置信息.
+type SendLocationInfo struct {
+	LocationX float64 `xml:"Location_X" json:"Location_X"`
+	LocationY float64 `xml:"Location_Y" json:"Location_Y"`
+	Scale     int64   `xml:"Scale"      json:"Scale"`
+	Label     string  `xml:"Label"      json:"Label"`
+	Poiname   string  `xml:"Poiname"    json:"Poiname"`
+}
+
+// MixMessage 是 Request 的公开别名, 给使用 Handler.SetMessageHandler 注册的回调使用,
+// 这样调用者不需要关心内部路由用的 bufferUnit.msgRequest 字段的真实类型.
+type MixMessage = Request
+
+// Text 文本消息
+type Text struct {
+	CommonHead
+	MsgId   int64  `xml:"MsgId"   json:"MsgId"`
+	Content string `xml:"Content" json:"Content"`
+}
+
+// Image 图片消息
+type Image struct {
+	CommonHead
+	MsgId   int64  `xml:"MsgId"   json:"MsgId"`
+	MediaId string `xml:"MediaId" json:"MediaId"`
+	PicURL  string `xml:"PicUrl"  json:"PicUrl"`
+}
+
+// Voice 语音消息
+type Voice struct {
+	CommonHead
+	MsgId       int64  `xml:"MsgId"       json:"MsgId"`
+	MediaId     string `xml:"MediaId"     json:"MediaId"`
+	Format      string `xml:"Format"      json:"Format"`
+	Recognition string `xml:"Recognition" json:"Recognition"`
+}
+
+// Video 视频消息
+type Video struct {
+	CommonHead
+	MsgId        int64  `xml:"MsgId"        json:"MsgId"`
+	MediaId      string `xml:"MediaId"      json:"MediaId"`
+	ThumbMediaId string `xml:"ThumbMediaId" json:"ThumbMediaId"`
+}
+
+// Location 地理位置消息
+type Location struct {
+	CommonHead
+	MsgId     int64   `xml:"MsgId"      json:"MsgId"`
+	LocationX float64 `xml:"Location_X" json:"Location_X"`
+	LocationY float64 `xml:"Location_Y" json:"Location_Y"`
+	Scale     int64   `xml:"Scale"      json:"Scale"`
+	Label     string  `xml:"Label"      json:"Label"`
+}
+
+// Link 链接消息
+type Link struct {
+	CommonHead
+	MsgId       int64  `xml:"MsgId"       json:"MsgId"`
+	Title       string `xml:"Title"       json:"Title"`
+	Description string `xml:"Description" json:"Description"`
+	URL         string `xml:"Url"         json:"Url"`
+}
+
+// MenuClickEvent 自定义菜单 click 事件
+type MenuClickEvent struct {
+	CommonHead
+	Event    string `xml:"Event"    json:"Event"`
+	EventKey string `xml:"EventKey" json:"EventKey"`
+}
+
+// MenuViewEvent 自定义菜单 view 事件
+type MenuViewEvent struct {
+	CommonHead
+	Event    string `xml:"Event"    json:"Event"`
+	EventKey string `xml:"EventKey" json:"EventKey"`
+}
+
+// LocationEvent 上报地理位置事件
+type LocationEvent struct {
+	CommonHead
+	Event     string  `xml:"Event"     json:"Event"`
+	Latitude  float64 `xml:"Latitude"  json:"Latitude"`
+	Longitude float64 `xml:"Longitude" json:"Longitude"`
+	Precision float64 `xml:"Precision" json:"Precision"`
+}
+
+// MerchantOrderEvent 微信小店订单付款事件
+type MerchantOrderEvent struct {
+	CommonHead
+	Event       string `xml:"Event"       json:"Event"`
+	OrderId     string `xml:"OrderId"     json:"OrderId"`
+	OrderStatus int64  `xml:"OrderStatus" json:"OrderStatus"`
+	ProductId   string `xml:"ProductId"   json:"ProductId"`
+	SkuInfo     string `xml:"SkuInfo"     json:"SkuInfo"`
+}
+
+// SubscribeEvent 普通关注事件
+type SubscribeEvent struct {
+	CommonHead
+	Event string `xml:"Event" json:"Event"`
+}
+
+// SubscribeByScanEvent 用户未关注时, 扫描带参数二维码关注公众号事件
+type SubscribeByScanEvent struct {
+	CommonHead
+	Event    string `xml:"Event"    json:"Event"`
+	EventKey string `xml:"EventKey" json:"EventKey"`
+	Ticket   string `xml:"Ticket"   json:"Ticket"`
+}
+
+// UnsubscribeEvent 取消关注事件
+type UnsubscribeEvent struct {
+	CommonHead
+	Event string `xml:"Event" json:"Event"`
+}
+
+// ScanEvent 用户已关注时, 扫描带参数二维码事件
+type ScanEvent struct {
+	CommonHead
+	Event    string `xml:"Event"    json:"Event"`
+	EventKey string `xml:"EventKey" json:"EventKey"`
+	Ticket   string `xml:"Ticket"   json:"Ticket"`
+}
+
+// MassSendJobFinishEvent 群发消息完成通知事件
+type MassSendJobFinishEvent struct {
+	CommonHead
+	Event       string `xml:"Event"       json:"Event"`
+	MsgId       int64  `xml:"MsgID"       json:"MsgID"`
+	Status      string `xml:"Status"      json:"Status"`
+	TotalCount  int64  `xml:"TotalCount"  json:"TotalCount"`
+	FilterCount int64  `xml:"FilterCount" json:"FilterCount"`
+	SentCount   int64  `xml:"SentCount"   json:"SentCount"`
+	ErrorCount  int64  `xml:"ErrorCount"  json:"ErrorCount"`
+}
+
+// ScanCodePushEvent 扫码推事件(弹出"消息接收中"提示框), 用户扫码后不回复用户.
+type ScanCodePushEvent struct {
+	CommonHead
+	Event        string       `xml:"Event"        json:"Event"`
+	EventKey     string       `xml:"EventKey"      json:"EventKey"`
+	ScanCodeInfo ScanCodeInfo `xml:"ScanCodeInfo"  json:"ScanCodeInfo"`
+}
+
+// ScanCodeWaitMsgEvent 扫码推事件(弹出"消息接收中"提示框), 用户扫码后回复用户.
+type ScanCodeWaitMsgEvent struct {
+	CommonHead
+	Event        string       `xml:"Event"        json:"Event"`
+	EventKey     string       `xml:"EventKey"      json:"EventKey"`
+	ScanCodeInfo ScanCodeInfo `xml:"ScanCodeInfo"  json:"ScanCodeInfo"`
+}
+
+// PicSysphotoEvent 弹出系统拍照发图事件.
+type PicSysphotoEvent struct {
+	CommonHead
+	Event        string       `xml:"Event"        json:"Event"`
+	EventKey     string       `xml:"EventKey"      json:"EventKey"`
+	SendPicsInfo SendPicsInfo `xml:"SendPicsInfo"  json:"SendPicsInfo"`
+}
+
+// PicPhotoOrAlbumEvent 弹出拍照或者相册发图事件.
+type PicPhotoOrAlbumEvent struct {
+	CommonHead
+	Event        string       `xml:"Event"        json:"Event"`
+	EventKey     string       `xml:"EventKey"      json:"EventKey"`
+	SendPicsInfo SendPicsInfo `xml:"SendPicsInfo"  json:"SendPicsInfo"`
+}
+
+// PicWeixinEvent 弹出微信相册发图器事件.
+type PicWeixinEvent struct {
+	CommonHead
+	Event        string       `xml:"Event"        json:"Event"`
+	EventKey     string       `xml:"EventKey"      json:"EventKey"`
+	SendPicsInfo SendPicsInfo `xml:"SendPicsInfo"  json:"SendPicsInfo"`
+}
+
+// LocationSelectEvent 弹出地理位置选择器事件.
+type LocationSelectEvent struct {
+	CommonHead
+	Event            string           `xml:"Event"            json:"Event"`
+	EventKey         string           `xml:"EventKey"          json:"EventKey"`
+	SendLocationInfo SendLocationInfo `xml:"SendLocationInfo" json:"SendLocationInfo"`
+}
+
+// TemplateSendJobFinishEvent 模板消息发送结果通知事件.
+type TemplateSendJobFinishEvent struct {
+	CommonHead
+	Event  string `xml:"Event"  json:"Event"`
+	MsgId  int64  `xml:"MsgID"  json:"MsgID"`
+	Status string `xml:"Status" json:"Status"`
+}
+
+// QualificationVerifySuccessEvent 资质认证成功(通过)事件.
+type QualificationVerifySuccessEvent struct {
+	CommonHead
+	Event       string `xml:"Event"       json:"Event"`
+	ExpiredTime int64  `xml:"ExpiredTime" json:"ExpiredTime"`
+}
+
+// QualificationVerifyFailEvent 资质认证失败事件.
+type QualificationVerifyFailEvent struct {
+	CommonHead
+	Event      string `xml:"Event"      json:"Event"`
+	FailTime   int64  `xml:"FailTime"   json:"FailTime"`
+	FailReason string `xml:"FailReason" json:"FailReason"`
+}
+
+// CardPassCheckEvent 卡券审核结果事件.
+type CardPassCheckEvent struct {
+	CommonHead
+	Event        string `xml:"Event"        json:"Event"`
+	CardId       string `xml:"CardId"       json:"CardId"`
+	RefuseReason string `xml:"RefuseReason" json:"RefuseReason"`
+}
+
+// UserGetCardEvent 用户领取卡券事件.
+type UserGetCardEvent struct {
+	CommonHead
+	Event        string `xml:"Event"        json:"Event"`
+	CardId       string `xml:"CardId"       json:"CardId"`
+	UserCardCode string `xml:"UserCardCode" json:"UserCardCode"`
+}
+
+// UserDelCardEvent 用户删除卡券事件.
+type UserDelCardEvent struct {
+	CommonHead
+	Event        string `xml:"Event"        json:"Event"`
+	CardId       string `xml:"CardId"       json:"CardId"`
+	UserCardCode string `xml:"UserCardCode" json:"UserCardCode"`
+}
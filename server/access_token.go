@@ -0,0 +1,18 @@
+package server
+
+import "github.com/chanxuehong/wechat/core"
+
+// AccessTokenServer 返回一个跟这个 Handler 共用 AppId/AppSecret/Cache 的
+// core.AccessTokenServer, 懒加载, 并发安全.
+//  被动回复之外, 调用微信主动接口(客服消息, 自定义菜单等)需要 access_token 时可以直接用它,
+//  它用 HandlerSetting.Cache 缓存 access_token, 多个实例共用同一个 Cache 时可以避免各自刷新抢占配额.
+func (handler *Handler) AccessTokenServer() *core.AccessTokenServer {
+	handler.accessTokenServerOnce.Do(func() {
+		handler.accessTokenServer = core.NewAccessTokenServer(
+			handler.setting.AppId,
+			handler.setting.AppSecret,
+			handler.setting.Cache,
+		)
+	})
+	return handler.accessTokenServer
+}
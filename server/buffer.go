@@ -0,0 +1,33 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/chanxuehong/wechat/message/request"
+)
+
+// bufferUnit 缓存了处理一次请求过程中需要用到的中间变量, 通过 sync.Pool 复用,
+// 减少 GC 压力.
+type bufferUnit struct {
+	msgBuf     *bytes.Buffer
+	msgRequest request.Request
+}
+
+func newBufferUnit() interface{} {
+	return &bufferUnit{
+		msgBuf: bytes.NewBuffer(make([]byte, 0, 1024)),
+	}
+}
+
+// getBufferUnitFromPool 从对象池中取出一个 *bufferUnit, 并重置其内部状态.
+func (handler *Handler) getBufferUnitFromPool() (unit *bufferUnit) {
+	unit = handler.bufferUnitPool.Get().(*bufferUnit)
+	unit.msgBuf.Reset()
+	unit.msgRequest = request.Request{}
+	return
+}
+
+// putBufferUnitToPool 把 *bufferUnit 放回对象池.
+func (handler *Handler) putBufferUnitToPool(unit *bufferUnit) {
+	handler.bufferUnitPool.Put(unit)
+}
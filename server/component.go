@@ -0,0 +1,479 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/chanxuehong/wechat/cache"
+	"github.com/chanxuehong/wechat/core"
+	"github.com/chanxuehong/wechat/message"
+	"github.com/chanxuehong/wechat/message/request"
+)
+
+// 第三方平台推送的 InfoType
+const (
+	componentInfoTypeVerifyTicket     = "component_verify_ticket"
+	componentInfoTypeAuthorized       = "authorized"
+	componentInfoTypeUnauthorized     = "unauthorized"
+	componentInfoTypeUpdateAuthorized = "updateauthorized"
+)
+
+const (
+	componentVerifyTicketCacheKeyPrefix  = "wechat.component_verify_ticket."
+	componentAccessTokenCacheKeyPrefix   = "wechat.component_access_token."
+	authorizerAccessTokenCacheKeyPrefix  = "wechat.authorizer_access_token."
+	authorizerRefreshTokenCacheKeyPrefix = "wechat.authorizer_refresh_token."
+	componentTokenExpiresInGap           = 10 * time.Minute
+)
+
+// AuthorizedHandlerFunc 处理 authorized/updateauthorized 事件.
+type AuthorizedHandlerFunc func(authorizerAppId, authorizationCode string, authorizationCodeExpiredTime int64)
+
+// UnauthorizedHandlerFunc 处理 unauthorized 事件.
+type UnauthorizedHandlerFunc func(authorizerAppId string)
+
+// ComponentMessageHandlerFunc 处理授权方(被代公众号)转发过来的消息(事件).
+type ComponentMessageHandlerFunc func(authorizerAppId string, msg *request.MixMessage) *message.Reply
+
+// ComponentHandlerSetting 是 ComponentHandler 的配置.
+type ComponentHandlerSetting struct {
+	// ComponentAppId, ComponentAppSecret 是第三方平台自己的 AppId/AppSecret.
+	ComponentAppId     string
+	ComponentAppSecret string
+
+	// Token, EncodingAESKey 是第三方平台后台配置的消息校验 Token 和消息加解密密钥.
+	Token          string
+	EncodingAESKey string
+
+	// Cache 用来缓存 component_verify_ticket, component_access_token 以及每个
+	// 授权方的 authorizer_access_token / authorizer_refresh_token.
+	Cache cache.Cache
+
+	AuthorizedHandler       AuthorizedHandlerFunc
+	UnauthorizedHandler     UnauthorizedHandlerFunc
+	UpdateAuthorizedHandler AuthorizedHandlerFunc
+	MessageHandler          ComponentMessageHandlerFunc
+
+	InvalidRequestHandler InvalidRequestHandlerFunc
+}
+
+func (setting *ComponentHandlerSetting) initialize(src *ComponentHandlerSetting) {
+	*setting = *src
+
+	if setting.InvalidRequestHandler == nil {
+		setting.InvalidRequestHandler = defaultInvalidRequestHandler
+	}
+}
+
+// ComponentHandler 处理微信第三方平台(开放平台代公众号/小程序运营)推送过来的
+// component_verify_ticket, 授权/取消授权/更新授权事件, 以及各个授权方转发过来的消息(事件).
+//  ComponentHandler 是 server.Handler 的兄弟: Handler 面向单个公众号, ComponentHandler
+//  面向第三方平台, 把模块从"单公众号"升级为可以托管多个公众号的 SaaS 中枢.
+type ComponentHandler struct {
+	setting ComponentHandlerSetting
+}
+
+// NewComponentHandler 创建一个 ComponentHandler.
+func NewComponentHandler(setting *ComponentHandlerSetting) (handler *ComponentHandler) {
+	if setting == nil {
+		panic("setting == nil")
+	}
+
+	handler = &ComponentHandler{}
+	handler.setting.initialize(setting)
+	return
+}
+
+// componentPush 是第三方平台推送的消息(事件)解密之后的明文 xml.
+//  InfoType 非空时是 ticket/授权类事件, 为空时是某个授权方转发过来的普通消息(事件),
+//  后者直接复用 request.Request 解析即可.
+type componentPush struct {
+	XMLName  struct{} `xml:"xml"`
+	AppId    string   `xml:"AppId"`
+	InfoType string   `xml:"InfoType"`
+
+	ComponentVerifyTicket string `xml:"ComponentVerifyTicket"`
+
+	AuthorizerAppid              string `xml:"AuthorizerAppid"`
+	AuthorizationCode            string `xml:"AuthorizationCode"`
+	AuthorizationCodeExpiredTime int64  `xml:"AuthorizationCodeExpiredTime"`
+}
+
+// ServeHTTP 实现 http.Handler 接口.
+//  GET 是"授权事件接收URL"/"消息与事件接收URL"在微信后台提交时的首次验证(echostr 回显),
+//  跟 Handler.ServeHTTP 的 GET 分支完全一样; POST 才是真正推送过来的消息(事件).
+func (handler *ComponentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "GET" {
+		handler.setting.InvalidRequestHandler(w, r, errors.New("ComponentHandler only accepts GET/POST"))
+		return
+	}
+
+	if r.URL == nil {
+		handler.setting.InvalidRequestHandler(w, r, errors.New("r.URL == nil"))
+		return
+	}
+	urlValues, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		handler.setting.InvalidRequestHandler(w, r, err)
+		return
+	}
+
+	if r.Method == "GET" { // 首次验证 ========================================
+		signature := urlValues.Get("signature")
+		timestamp := urlValues.Get("timestamp")
+		nonce := urlValues.Get("nonce")
+		echostr := urlValues.Get("echostr")
+		if signature == "" || timestamp == "" || nonce == "" || echostr == "" {
+			handler.setting.InvalidRequestHandler(w, r, errors.New("signature/timestamp/nonce/echostr is empty"))
+			return
+		}
+		if !checkSignature(signature, timestamp, nonce, handler.setting.Token) {
+			handler.setting.InvalidRequestHandler(w, r, errors.New("check signature failed"))
+			return
+		}
+		io.WriteString(w, echostr)
+		return
+	}
+
+	timestamp := urlValues.Get("timestamp")
+	nonce := urlValues.Get("nonce")
+	msgSig := urlValues.Get("msg_signature")
+	if timestamp == "" || nonce == "" || msgSig == "" {
+		handler.setting.InvalidRequestHandler(w, r, errors.New("timestamp/nonce/msg_signature is empty"))
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err = io.Copy(&buf, r.Body); err != nil {
+		handler.setting.InvalidRequestHandler(w, r, err)
+		return
+	}
+
+	var envelope encryptedRequest
+	if err = xml.Unmarshal(buf.Bytes(), &envelope); err != nil {
+		handler.setting.InvalidRequestHandler(w, r, err)
+		return
+	}
+	if msgSignature(handler.setting.Token, timestamp, nonce, envelope.Encrypt) != msgSig {
+		handler.setting.InvalidRequestHandler(w, r, errors.New("check msg_signature failed"))
+		return
+	}
+
+	plainXML, appId, err := decryptMsg(handler.setting.EncodingAESKey, envelope.Encrypt)
+	if err != nil {
+		handler.setting.InvalidRequestHandler(w, r, err)
+		return
+	}
+	if appId != handler.setting.ComponentAppId {
+		handler.setting.InvalidRequestHandler(w, r, errors.New("the appid in the decrypted message does not match ComponentAppId"))
+		return
+	}
+
+	var push componentPush
+	if err = xml.Unmarshal(plainXML, &push); err != nil {
+		handler.setting.InvalidRequestHandler(w, r, err)
+		return
+	}
+
+	switch push.InfoType {
+	case componentInfoTypeVerifyTicket:
+		handler.cacheComponentVerifyTicket(push.ComponentVerifyTicket)
+
+	case componentInfoTypeAuthorized:
+		if err = handler.handleAuthorized(push.AuthorizerAppid, push.AuthorizationCode, push.AuthorizationCodeExpiredTime); err != nil {
+			log.Printf("wechat: component handleAuthorized(%s) failed: %v", push.AuthorizerAppid, err)
+		} else if handler.setting.AuthorizedHandler != nil {
+			handler.setting.AuthorizedHandler(push.AuthorizerAppid, push.AuthorizationCode, push.AuthorizationCodeExpiredTime)
+		}
+
+	case componentInfoTypeUpdateAuthorized:
+		if err = handler.handleAuthorized(push.AuthorizerAppid, push.AuthorizationCode, push.AuthorizationCodeExpiredTime); err != nil {
+			log.Printf("wechat: component handleAuthorized(%s) failed: %v", push.AuthorizerAppid, err)
+		} else if handler.setting.UpdateAuthorizedHandler != nil {
+			handler.setting.UpdateAuthorizedHandler(push.AuthorizerAppid, push.AuthorizationCode, push.AuthorizationCodeExpiredTime)
+		}
+
+	case componentInfoTypeUnauthorized:
+		if handler.setting.Cache != nil {
+			handler.setting.Cache.Delete(authorizerAccessTokenCacheKeyPrefix + push.AuthorizerAppid)
+			handler.setting.Cache.Delete(authorizerRefreshTokenCacheKeyPrefix + push.AuthorizerAppid)
+		}
+		if handler.setting.UnauthorizedHandler != nil {
+			handler.setting.UnauthorizedHandler(push.AuthorizerAppid)
+		}
+
+	case "":
+		// InfoType 为空, 是某个授权方转发过来的普通消息(事件), ToUserName 就是授权方的 AppId.
+		var msgReq request.Request
+		if err = xml.Unmarshal(plainXML, &msgReq); err != nil {
+			handler.setting.InvalidRequestHandler(w, r, err)
+			return
+		}
+		if handler.setting.MessageHandler == nil {
+			w.Write([]byte("success"))
+			return
+		}
+
+		reply := handler.setting.MessageHandler(msgReq.ToUserName, &msgReq)
+		if reply == nil {
+			w.Write([]byte("success"))
+			return
+		}
+
+		plainReplyXML := marshalReply(reply, msgReq.FromUserName, msgReq.ToUserName)
+		cipherXML, err := encryptEnvelope(handler.setting.EncodingAESKey, handler.setting.Token,
+			handler.setting.ComponentAppId, plainReplyXML, timestamp, nonce)
+		if err != nil {
+			handler.setting.InvalidRequestHandler(w, r, err)
+			return
+		}
+		w.Write(cipherXML)
+		return
+	}
+
+	w.Write([]byte("success"))
+}
+
+// encryptEnvelope 跟 Handler.Reply 类似, 只是 appId 由调用者显式传入(第三方平台场景
+// 下加密用的是 ComponentAppId, 不是 Handler.setting.AppId).
+func encryptEnvelope(encodingAESKey, token, appId string, msgXML []byte, timestamp, nonce string) ([]byte, error) {
+	encryptedMsg, err := encryptMsg(encodingAESKey, appId, msgXML)
+	if err != nil {
+		return nil, err
+	}
+	sig := msgSignature(token, timestamp, nonce, encryptedMsg)
+	return []byte(fmt.Sprintf(encryptedResponseFormat, encryptedMsg, sig, timestamp, nonce)), nil
+}
+
+func (handler *ComponentHandler) cacheComponentVerifyTicket(ticket string) {
+	if handler.setting.Cache == nil || ticket == "" {
+		return
+	}
+	handler.setting.Cache.Set(
+		componentVerifyTicketCacheKeyPrefix+handler.setting.ComponentAppId,
+		ticket,
+		2*time.Hour,
+	)
+}
+
+// handleAuthorized 用 authorizationCode 换取 authorizer_access_token/refresh_token 并缓存,
+// 后续 GetAuthorizerAccessToken 就可以用 refresh_token 自动刷新了.
+//  返回的 error 非 nil 时说明换取/缓存没有成功, 调用者不应该把这次授权当作已经生效,
+//  也就不应该再去调用 AuthorizedHandler/UpdateAuthorizedHandler.
+func (handler *ComponentHandler) handleAuthorized(authorizerAppId, authorizationCode string, _ int64) error {
+	if authorizationCode == "" {
+		return errors.New("AuthorizationCode is empty")
+	}
+	if handler.setting.Cache == nil {
+		return errors.New("ComponentHandlerSetting.Cache is nil, cannot cache authorizer token")
+	}
+
+	result, err := handler.queryAuth(authorizationCode)
+	if err != nil {
+		return err
+	}
+
+	handler.setting.Cache.Set(
+		authorizerAccessTokenCacheKeyPrefix+authorizerAppId,
+		result.AuthorizerAccessToken,
+		time.Duration(result.ExpiresIn)*time.Second-componentTokenExpiresInGap,
+	)
+	handler.setting.Cache.Set(
+		authorizerRefreshTokenCacheKeyPrefix+authorizerAppId,
+		result.AuthorizerRefreshToken,
+		0, // refresh_token 长期有效
+	)
+	return nil
+}
+
+// componentAccessTokenResponse 是 api_component_token 接口的响应.
+type componentAccessTokenResponse struct {
+	ComponentAccessToken string `json:"component_access_token"`
+	ExpiresIn            int64  `json:"expires_in"`
+}
+
+// componentAccessToken 返回一个有效的 component_access_token, 从缓存读取,
+// 缓存未命中时用 component_verify_ticket 向微信服务器申请并刷新缓存.
+func (handler *ComponentHandler) componentAccessToken() (string, error) {
+	if handler.setting.Cache == nil {
+		return "", errors.New("ComponentHandlerSetting.Cache is nil, cannot cache component access token")
+	}
+
+	key := componentAccessTokenCacheKeyPrefix + handler.setting.ComponentAppId
+	if val := handler.setting.Cache.Get(key); val != nil {
+		if token, ok := val.(string); ok {
+			return token, nil
+		}
+	}
+
+	ticketVal := handler.setting.Cache.Get(componentVerifyTicketCacheKeyPrefix + handler.setting.ComponentAppId)
+	ticket, _ := ticketVal.(string)
+	if ticket == "" {
+		return "", errors.New("component_verify_ticket not ready yet")
+	}
+
+	reqBody, err := json.Marshal(map[string]string{
+		"component_appid":         handler.setting.ComponentAppId,
+		"component_appsecret":     handler.setting.ComponentAppSecret,
+		"component_verify_ticket": ticket,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.Post(
+		"https://api.weixin.qq.com/cgi-bin/component/api_component_token",
+		"application/json", bytes.NewReader(reqBody),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		core.Error
+		componentAccessTokenResponse
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 {
+		return "", &result.Error
+	}
+
+	if handler.setting.Cache != nil {
+		ttl := time.Duration(result.ExpiresIn)*time.Second - componentTokenExpiresInGap
+		if ttl > 0 {
+			handler.setting.Cache.Set(key, result.ComponentAccessToken, ttl)
+		}
+	}
+	return result.ComponentAccessToken, nil
+}
+
+// queryAuthResult 是 api_query_auth 接口里 authorization_info 部分.
+type queryAuthResult struct {
+	AuthorizerAppid        string `json:"authorizer_appid"`
+	AuthorizerAccessToken  string `json:"authorizer_access_token"`
+	ExpiresIn              int64  `json:"expires_in"`
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+}
+
+// queryAuth 用 authorizationCode 向微信服务器换取 authorizer_access_token/refresh_token.
+func (handler *ComponentHandler) queryAuth(authorizationCode string) (*queryAuthResult, error) {
+	componentAccessToken, err := handler.componentAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody, err := json.Marshal(map[string]string{
+		"component_appid":    handler.setting.ComponentAppId,
+		"authorization_code": authorizationCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf(
+		"https://api.weixin.qq.com/cgi-bin/component/api_query_auth?component_access_token=%s",
+		componentAccessToken,
+	)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		core.Error
+		AuthorizationInfo queryAuthResult `json:"authorization_info"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.ErrCode != 0 {
+		return nil, &result.Error
+	}
+	return &result.AuthorizationInfo, nil
+}
+
+// authorizerTokenResponse 是 api_authorizer_token 接口的响应.
+type authorizerTokenResponse struct {
+	core.Error
+	AuthorizerAccessToken  string `json:"authorizer_access_token"`
+	ExpiresIn              int64  `json:"expires_in"`
+	AuthorizerRefreshToken string `json:"authorizer_refresh_token"`
+}
+
+// GetAuthorizerAccessToken 返回 authorizerAppId 对应授权方的 access_token, 优先从缓存读取,
+// 缓存未命中时用之前保存的 authorizer_refresh_token 自动刷新.
+func (handler *ComponentHandler) GetAuthorizerAccessToken(authorizerAppId string) (string, error) {
+	if handler.setting.Cache == nil {
+		return "", errors.New("ComponentHandlerSetting.Cache is nil, cannot cache authorizer access token")
+	}
+
+	key := authorizerAccessTokenCacheKeyPrefix + authorizerAppId
+	if val := handler.setting.Cache.Get(key); val != nil {
+		if token, ok := val.(string); ok {
+			return token, nil
+		}
+	}
+
+	refreshTokenVal := handler.setting.Cache.Get(authorizerRefreshTokenCacheKeyPrefix + authorizerAppId)
+	refreshToken, _ := refreshTokenVal.(string)
+	if refreshToken == "" {
+		return "", fmt.Errorf("authorizer %s has not been authorized yet", authorizerAppId)
+	}
+
+	componentAccessToken, err := handler.componentAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	reqBody, err := json.Marshal(map[string]string{
+		"component_appid":          handler.setting.ComponentAppId,
+		"authorizer_appid":         authorizerAppId,
+		"authorizer_refresh_token": refreshToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf(
+		"https://api.weixin.qq.com/cgi-bin/component/api_authorizer_token?component_access_token=%s",
+		componentAccessToken,
+	)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result authorizerTokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.ErrCode != 0 {
+		return "", &result.Error
+	}
+
+	ttl := time.Duration(result.ExpiresIn)*time.Second - componentTokenExpiresInGap
+	if ttl > 0 {
+		handler.setting.Cache.Set(key, result.AuthorizerAccessToken, ttl)
+	}
+	handler.setting.Cache.Set(authorizerRefreshTokenCacheKeyPrefix+authorizerAppId, result.AuthorizerRefreshToken, 0)
+
+	return result.AuthorizerAccessToken, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/gob"
+	"time"
+
+	"github.com/chanxuehong/wechat/cache"
+)
+
+func init() {
+	gob.Register([]HistoryMessage{})
+}
+
+// messageContextCacheKeyPrefix 下面拼上 OpenID 就是该用户会话历史在 Cache 里的 key.
+const messageContextCacheKeyPrefix = "wechat.msgctx."
+
+// HistoryMessage 是 MessageContext 保存的一条历史消息, 只保留多轮对话常用的字段.
+type HistoryMessage struct {
+	MsgType    string
+	Content    string
+	CreateTime int64
+}
+
+// MessageContext 按 OpenID 保存每个用户最近的 N 条消息, 用于实现"回复 1/2/3"
+// 这类依赖上一轮消息的多轮对话流程, 底层存储可以是任意的 cache.Cache 实现.
+//  跟其他微信 SDK 里 CurrentMessageContext.ExpireMinutes 的设计思路一致.
+type MessageContext struct {
+	cache         cache.Cache
+	maxHistory    int
+	expireMinutes int
+}
+
+// NewMessageContext 创建一个 MessageContext.
+//  maxHistory 是每个用户保留的历史消息条数, expireMinutes 是会话的过期时间(分钟),
+//  其中任何一个 <= 0 时, 该项使用默认值(maxHistory=10, expireMinutes=30).
+func NewMessageContext(c cache.Cache, maxHistory, expireMinutes int) *MessageContext {
+	if maxHistory <= 0 {
+		maxHistory = 10
+	}
+	if expireMinutes <= 0 {
+		expireMinutes = 30
+	}
+	return &MessageContext{
+		cache:         c,
+		maxHistory:    maxHistory,
+		expireMinutes: expireMinutes,
+	}
+}
+
+// History 返回 openId 对应用户的历史消息, 按时间先后顺序排列.
+//  ctx 为 nil 时返回 nil, 这样没有配置 MessageContext 的 Handler 也能安全调用.
+func (ctx *MessageContext) History(openId string) []HistoryMessage {
+	if ctx == nil || ctx.cache == nil {
+		return nil
+	}
+
+	val := ctx.cache.Get(messageContextCacheKeyPrefix + openId)
+	if val == nil {
+		return nil
+	}
+	history, _ := val.([]HistoryMessage)
+	return history
+}
+
+// Append 把一条消息追加到 openId 对应用户的历史记录里, 超过 maxHistory 时丢弃最旧的.
+//  ctx 为 nil 时是一个空操作.
+func (ctx *MessageContext) Append(openId string, msg HistoryMessage) error {
+	if ctx == nil || ctx.cache == nil {
+		return nil
+	}
+
+	history := ctx.History(openId)
+	history = append(history, msg)
+	if len(history) > ctx.maxHistory {
+		history = history[len(history)-ctx.maxHistory:]
+	}
+
+	return ctx.cache.Set(
+		messageContextCacheKeyPrefix+openId,
+		history,
+		time.Duration(ctx.expireMinutes)*time.Minute,
+	)
+}
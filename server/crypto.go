@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// aesMsgPadBlockSize 是微信消息加解密使用的 PKCS7 填充块大小, 注意不是标准的 16,
+// 而是 32, 这是微信官方 WXBizMsgCrypt 的约定.
+const aesMsgPadBlockSize = 32
+
+// encryptedRequest 对应兼容模式/安全模式下微信推送过来的密文 xml.
+type encryptedRequest struct {
+	XMLName struct{} `xml:"xml"`
+	Encrypt string   `xml:"Encrypt"`
+}
+
+// encryptedResponseFormat 是被动回复时套在密文外面的 xml 信封模板.
+//  encoding/xml 不支持原生输出 CDATA, 微信官方示例也是直接拼接字符串, 这里沿用这个做法.
+const encryptedResponseFormat = `<xml>` +
+	`<Encrypt><![CDATA[%s]]></Encrypt>` +
+	`<MsgSignature><![CDATA[%s]]></MsgSignature>` +
+	`<TimeStamp>%s</TimeStamp>` +
+	`<Nonce><![CDATA[%s]]></Nonce>` +
+	`</xml>`
+
+// aesKey 根据公众号后台配置的 EncodingAESKey(43 个字符) 还原出 32 字节的 AES 密钥.
+func aesKey(encodingAESKey string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errors.New("EncodingAESKey 不合法, 解码后不是 32 字节")
+	}
+	return key, nil
+}
+
+// msgSignature 计算 sha1(sort(token, timestamp, nonce, encryptedMsg)).
+func msgSignature(token, timestamp, nonce, encryptedMsg string) string {
+	strs := []string{token, timestamp, nonce, encryptedMsg}
+	sort.Strings(strs)
+
+	h := sha1.New()
+	for _, s := range strs {
+		io.WriteString(h, s)
+	}
+	return hexEncode(h.Sum(nil))
+}
+
+func hexEncode(src []byte) string {
+	const hexDigits = "0123456789abcdef"
+	dst := make([]byte, len(src)*2)
+	for i, b := range src {
+		dst[i*2] = hexDigits[b>>4]
+		dst[i*2+1] = hexDigits[b&0xf]
+	}
+	return string(dst)
+}
+
+// pkcs7Pad 对 plainText 做 PKCS7 填充, 使其长度是 blockSize 的整数倍.
+func pkcs7Pad(plainText []byte, blockSize int) []byte {
+	padLen := blockSize - len(plainText)%blockSize
+	if padLen == 0 {
+		padLen = blockSize
+	}
+	pad := bytes.Repeat([]byte{byte(padLen)}, padLen)
+	return append(plainText, pad...)
+}
+
+// pkcs7Unpad 去除 PKCS7 填充.
+func pkcs7Unpad(plainText []byte) ([]byte, error) {
+	n := len(plainText)
+	if n == 0 {
+		return nil, errors.New("明文长度为 0, 无法去除 PKCS7 填充")
+	}
+	padLen := int(plainText[n-1])
+	if padLen == 0 || padLen > n || padLen > aesMsgPadBlockSize {
+		return nil, errors.New("PKCS7 填充长度不合法")
+	}
+	return plainText[:n-padLen], nil
+}
+
+// aesCBCDecrypt 用 key 对 cipherText 做 AES-256-CBC 解密, IV 取 key 的前 16 字节,
+// 跟微信的约定一致(真正的随机 IV 已经内嵌在明文的前 16 字节里, 见 decryptMsg).
+func aesCBCDecrypt(key, cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText)%block.BlockSize() != 0 {
+		return nil, errors.New("密文长度不是 block size 的整数倍")
+	}
+	iv := key[:block.BlockSize()]
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plainText, cipherText)
+	return plainText, nil
+}
+
+func aesCBCEncrypt(key, plainText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := key[:block.BlockSize()]
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(cipherText, plainText)
+	return cipherText, nil
+}
+
+// decryptMsg 解密微信推送过来的 <Encrypt/> 密文, 返回明文 xml 以及其中携带的 appId.
+//
+// 密文的明文格式为: random(16B) + msgLen(4B, 网络字节序) + msg + appId.
+func decryptMsg(encodingAESKey, encryptedMsg string) (msgXML []byte, appId string, err error) {
+	key, err := aesKey(encodingAESKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cipherText, err := base64.StdEncoding.DecodeString(encryptedMsg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plainText, err := aesCBCDecrypt(key, cipherText)
+	if err != nil {
+		return nil, "", err
+	}
+	plainText, err = pkcs7Unpad(plainText)
+	if err != nil {
+		return nil, "", err
+	}
+
+	const randLen = 16
+	const msgLenFieldSize = 4
+	if len(plainText) < randLen+msgLenFieldSize {
+		return nil, "", errors.New("解密后的明文长度不够")
+	}
+
+	msgLen := binary.BigEndian.Uint32(plainText[randLen : randLen+msgLenFieldSize])
+	msgStart := randLen + msgLenFieldSize
+	msgEnd := msgStart + int(msgLen)
+	if msgEnd > len(plainText) {
+		return nil, "", errors.New("解密后的明文携带的 msg 长度字段不合法")
+	}
+
+	return plainText[msgStart:msgEnd], string(plainText[msgEnd:]), nil
+}
+
+// encryptMsg 按照微信的约定加密 msgXML, 生成可以直接放进 <Encrypt/> 里的 base64 密文.
+func encryptMsg(encodingAESKey, appId string, msgXML []byte) (string, error) {
+	key, err := aesKey(encodingAESKey)
+	if err != nil {
+		return "", err
+	}
+
+	randBytes := make([]byte, 16)
+	if _, err = rand.Read(randBytes); err != nil {
+		return "", err
+	}
+
+	msgLenField := make([]byte, 4)
+	binary.BigEndian.PutUint32(msgLenField, uint32(len(msgXML)))
+
+	plainText := make([]byte, 0, 16+4+len(msgXML)+len(appId))
+	plainText = append(plainText, randBytes...)
+	plainText = append(plainText, msgLenField...)
+	plainText = append(plainText, msgXML...)
+	plainText = append(plainText, []byte(appId)...)
+	plainText = pkcs7Pad(plainText, aesMsgPadBlockSize)
+
+	cipherText, err := aesCBCEncrypt(key, plainText)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+// decryptRequestBody 校验 msg_signature 并解密兼容模式/安全模式下微信推送过来的请求体,
+// 返回明文 xml, 调用者应该用明文 xml 重新走一遍普通的消息路由.
+func (handler *Handler) decryptRequestBody(body []byte, msgSig, timestamp, nonce string) (msgXML []byte, err error) {
+	var req encryptedRequest
+	if err = xml.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	if msgSignature(handler.setting.Token, timestamp, nonce, req.Encrypt) != msgSig {
+		return nil, errors.New("check msg_signature failed")
+	}
+
+	msgXML, appId, err := decryptMsg(handler.setting.EncodingAESKey, req.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+	if handler.setting.AppId != "" && appId != handler.setting.AppId {
+		return nil, errors.New("the appid in the decrypted message does not match AppId in HandlerSetting")
+	}
+	return msgXML, nil
+}
+
+// Reply 把被动回复的明文 msgXML 加密, 返回可以直接写给微信服务器的 xml 信封.
+//  timestamp, nonce 可以使用收到请求时微信传过来的值, 也可以自己生成.
+func (handler *Handler) Reply(msgXML []byte, timestamp, nonce string) ([]byte, error) {
+	encryptedMsg, err := encryptMsg(handler.setting.EncodingAESKey, handler.setting.AppId, msgXML)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := msgSignature(handler.setting.Token, timestamp, nonce, encryptedMsg)
+	resp := fmt.Sprintf(encryptedResponseFormat, encryptedMsg, sig, timestamp, nonce)
+	return []byte(resp), nil
+}
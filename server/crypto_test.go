@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// randEncodingAESKey 生成一个测试用的, 格式跟公众号后台配置的 EncodingAESKey 一样的
+// 43 字符 key(标准 base64, 去掉末尾的 "=").
+func randEncodingAESKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimRight(base64.StdEncoding.EncodeToString(key), "=")
+}
+
+// TestEncryptDecryptMsgRoundTrip 验证 encryptMsg/decryptMsg 互为逆操作, appId 和
+// 消息明文都能原样还原.
+func TestEncryptDecryptMsgRoundTrip(t *testing.T) {
+	encodingAESKey := randEncodingAESKey(t)
+	appId := "wx1234567890abcdef"
+	msgXML := []byte(`<xml><ToUserName><![CDATA[touser]]></ToUserName><FromUserName><![CDATA[fromuser]]></FromUserName></xml>`)
+
+	encryptedMsg, err := encryptMsg(encodingAESKey, appId, msgXML)
+	if err != nil {
+		t.Fatalf("encryptMsg: %v", err)
+	}
+
+	gotXML, gotAppId, err := decryptMsg(encodingAESKey, encryptedMsg)
+	if err != nil {
+		t.Fatalf("decryptMsg: %v", err)
+	}
+	if gotAppId != appId {
+		t.Errorf("appId = %q, want %q", gotAppId, appId)
+	}
+	if !bytes.Equal(gotXML, msgXML) {
+		t.Errorf("msgXML = %q, want %q", gotXML, msgXML)
+	}
+}
+
+// TestCheckSignatureLongInput 验证 checkSignature 在 token+timestamp+nonce
+// 的总长度超过一个 sha1 摘要的 hex 长度(40 字节)时不会 panic, 并且能正确校验出匹配/
+// 不匹配的签名.
+func TestCheckSignatureLongInput(t *testing.T) {
+	token := "a-fairly-long-token-for-testing"
+	timestamp := "1234567890"
+	nonce := "a-fairly-long-nonce-value-from-wechat"
+
+	signature := sortedSHA1Hex(token, timestamp, nonce)
+	if !checkSignature(signature, timestamp, nonce, token) {
+		t.Error("checkSignature with the correct signature should return true")
+	}
+	if checkSignature("not-the-real-signature", timestamp, nonce, token) {
+		t.Error("checkSignature with a wrong signature should return false")
+	}
+}
+
+// sortedSHA1Hex 独立实现一遍 sha1(sort(strs...)) 的 hex 编码, 作为跟 checkSignature
+// 比对的对照组, 而不是直接复用被测代码.
+func sortedSHA1Hex(strs ...string) string {
+	sorted := append([]string(nil), strs...)
+	sort.Strings(sorted)
+
+	h := sha1.New()
+	for _, s := range sorted {
+		io.WriteString(h, s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
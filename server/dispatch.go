@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chanxuehong/wechat/message"
+	"github.com/chanxuehong/wechat/message/request"
+)
+
+// replyEnvelopeFormat 是被动回复消息(明文)的 xml 信封模板, 占位符依次是
+// ToUserName, FromUserName, CreateTime(%d), MsgType, 以及消息自身的 xml 片段.
+const replyEnvelopeFormat = `<xml>` +
+	`<ToUserName><![CDATA[%s]]></ToUserName>` +
+	`<FromUserName><![CDATA[%s]]></FromUserName>` +
+	`<CreateTime>%d</CreateTime>` +
+	`<MsgType><![CDATA[%s]]></MsgType>` +
+	`%s` +
+	`</xml>`
+
+// SetMessageHandler 注册一个统一的消息处理函数, 它的返回值(*message.Reply)会被
+// Handler 自动序列化成被动回复 xml 并写回给微信服务器.
+//  应该在 Handler 开始处理请求之前调用, 调用之后不应该再并发修改.
+func (handler *Handler) SetMessageHandler(fn MessageHandlerFunc) {
+	handler.setting.MessageHandler = fn
+}
+
+// marshalReply 把 reply 序列化成被动回复 xml(明文), toUserName/fromUserName 是
+// 回复给微信服务器的消息的收发双方, 即原始请求里的发送方/接收方对调.
+func marshalReply(reply *message.Reply, toUserName, fromUserName string) []byte {
+	fragment := ""
+	if reply.MsgData != nil {
+		fragment = reply.MsgData.XMLFragment()
+	}
+	envelope := fmt.Sprintf(
+		replyEnvelopeFormat,
+		toUserName, fromUserName, time.Now().Unix(), reply.MsgType, fragment,
+	)
+	return []byte(envelope)
+}
+
+// dispatchMessage 用 setting.MessageHandler 处理一条已经解析好的消息(事件), 并把
+// 返回的 *message.Reply 写回给微信服务器. 如果是兼容模式/安全模式, 写回之前会先加密.
+func (handler *Handler) dispatchMessage(w http.ResponseWriter, r *http.Request, msgReq *request.MixMessage,
+	timestamp, nonce string) {
+
+	ctx := handler.setting.MessageContext
+	ctx.Append(msgReq.FromUserName, HistoryMessage{
+		MsgType:    msgReq.MsgType,
+		Content:    msgReq.Content,
+		CreateTime: msgReq.CreateTime,
+	})
+
+	reply := handler.setting.MessageHandler(ctx, msgReq)
+	if reply == nil {
+		w.Write([]byte("success"))
+		return
+	}
+
+	plainXML := marshalReply(reply, msgReq.FromUserName, msgReq.ToUserName)
+
+	if handler.setting.EncodingAESKey == "" {
+		w.Write(plainXML)
+		return
+	}
+
+	cipherXML, err := handler.Reply(plainXML, timestamp, nonce)
+	if err != nil {
+		handler.setting.InvalidRequestHandler(w, r, err)
+		return
+	}
+	w.Write(cipherXML)
+}
@@ -8,6 +8,7 @@ package server
 import (
 	"encoding/xml"
 	"errors"
+	"github.com/chanxuehong/wechat/core"
 	"github.com/chanxuehong/wechat/message/request"
 	"io"
 	"net/http"
@@ -26,6 +27,10 @@ type Handler struct {
 	//  NOTE: require go1.3+ , 如果你的环境不满足这个条件, 可以自己实现一个简单的 Pool,
 	//        see github.com/chanxuehong/util/pool
 	bufferUnitPool sync.Pool
+
+	// accessTokenServer 是懒加载的, 见 AccessTokenServer().
+	accessTokenServerOnce sync.Once
+	accessTokenServer     *core.AccessTokenServer
 }
 
 func NewHandler(setting *HandlerSetting) (handler *Handler) {
@@ -74,7 +79,7 @@ func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		bufferUnit := handler.getBufferUnitFromPool() // *bufferUnit
 		defer handler.putBufferUnitToPool(bufferUnit) // important!
 
-		if !checkSignature(signature, timestamp, nonce, handler.setting.Token, bufferUnit.signatureBuf[:]) {
+		if !checkSignature(signature, timestamp, nonce, handler.setting.Token) {
 			handler.setting.InvalidRequestHandler(w, r, errors.New("check signature failed"))
 			return
 		}
@@ -85,12 +90,33 @@ func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		msgReqBody := bufferUnit.msgBuf.Bytes()
+
+		// 兼容模式/安全模式下, 请求体是一个包裹着密文的 xml, 需要先校验 msg_signature
+		// 并解密出真正的消息明文, 之后的路由逻辑对两种模式完全一样.
+		if handler.setting.EncodingAESKey != "" && urlValues.Get("encrypt_type") == "aes" {
+			msgSig := urlValues.Get("msg_signature")
+			if msgSig == "" {
+				handler.setting.InvalidRequestHandler(w, r, errors.New("msg_signature is empty"))
+				return
+			}
+			if msgReqBody, err = handler.decryptRequestBody(msgReqBody, msgSig, timestamp, nonce); err != nil {
+				handler.setting.InvalidRequestHandler(w, r, err)
+				return
+			}
+		}
+
 		msgReq := &bufferUnit.msgRequest // & 不能丢
 		if err = xml.Unmarshal(msgReqBody, msgReq); err != nil {
 			handler.setting.InvalidRequestHandler(w, r, err)
 			return
 		}
 
+		// 统一消息处理函数优先生效, 这样用户可以不必关心分发到具体类型处理函数的细节.
+		if handler.setting.MessageHandler != nil {
+			handler.dispatchMessage(w, r, msgReq, timestamp, nonce)
+			return
+		}
+
 		// request router, 可一个根据自己的实际业务调整顺序!
 		switch msgReq.MsgType {
 		case request.MSG_TYPE_TEXT:
@@ -188,6 +214,113 @@ func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 				handler.setting.MassSendJobFinishEventHandler(w, r, &event)
 
+			case request.EVENT_TYPE_SCANCODEPUSH:
+				event := request.ScanCodePushEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					EventKey:     msgReq.EventKey,
+					ScanCodeInfo: msgReq.ScanCodeInfo,
+				}
+				handler.setting.ScanCodePushEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_SCANCODEWAITMSG:
+				event := request.ScanCodeWaitMsgEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					EventKey:     msgReq.EventKey,
+					ScanCodeInfo: msgReq.ScanCodeInfo,
+				}
+				handler.setting.ScanCodeWaitMsgEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_PICSYSPHOTO:
+				event := request.PicSysphotoEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					EventKey:     msgReq.EventKey,
+					SendPicsInfo: msgReq.SendPicsInfo,
+				}
+				handler.setting.PicSysphotoEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_PICPHOTOORALBUM:
+				event := request.PicPhotoOrAlbumEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					EventKey:     msgReq.EventKey,
+					SendPicsInfo: msgReq.SendPicsInfo,
+				}
+				handler.setting.PicPhotoOrAlbumEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_PICWEIXIN:
+				event := request.PicWeixinEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					EventKey:     msgReq.EventKey,
+					SendPicsInfo: msgReq.SendPicsInfo,
+				}
+				handler.setting.PicWeixinEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_LOCATIONSELECT:
+				event := request.LocationSelectEvent{
+					CommonHead:       msgReq.CommonHead,
+					Event:            msgReq.Event,
+					EventKey:         msgReq.EventKey,
+					SendLocationInfo: msgReq.SendLocationInfo,
+				}
+				handler.setting.LocationSelectEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_TEMPLATESENDJOBFINISH:
+				event := request.TemplateSendJobFinishEvent{
+					CommonHead: msgReq.CommonHead,
+					Event:      msgReq.Event,
+					MsgId:      msgReq.MsgID, // NOTE
+					Status:     msgReq.Status,
+				}
+				handler.setting.TemplateSendJobFinishEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_QUALIFICATIONVERIFYSUCCESS:
+				event := request.QualificationVerifySuccessEvent{
+					CommonHead:  msgReq.CommonHead,
+					Event:       msgReq.Event,
+					ExpiredTime: msgReq.ExpiredTime,
+				}
+				handler.setting.QualificationVerifySuccessEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_QUALIFICATIONVERIFYFAIL:
+				event := request.QualificationVerifyFailEvent{
+					CommonHead: msgReq.CommonHead,
+					Event:      msgReq.Event,
+					FailTime:   msgReq.FailTime,
+					FailReason: msgReq.FailReason,
+				}
+				handler.setting.QualificationVerifyFailEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_CARDPASSCHECK:
+				event := request.CardPassCheckEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					CardId:       msgReq.CardId,
+					RefuseReason: msgReq.RefuseReason,
+				}
+				handler.setting.CardPassCheckEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_USERGETCARD:
+				event := request.UserGetCardEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					CardId:       msgReq.CardId,
+					UserCardCode: msgReq.UserCardCode,
+				}
+				handler.setting.UserGetCardEventHandler(w, r, &event)
+
+			case request.EVENT_TYPE_USERDELCARD:
+				event := request.UserDelCardEvent{
+					CommonHead:   msgReq.CommonHead,
+					Event:        msgReq.Event,
+					CardId:       msgReq.CardId,
+					UserCardCode: msgReq.UserCardCode,
+				}
+				handler.setting.UserDelCardEventHandler(w, r, &event)
+
 			default: // unknown event
 				// 因为 msgReqBody 底层需要缓存, 所以这里需要一个副本
 				msgReqBodyCopy := make([]byte, len(msgReqBody))
@@ -285,7 +418,7 @@ func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		bufferUnit := handler.getBufferUnitFromPool() // *bufferUnit
 		defer handler.putBufferUnitToPool(bufferUnit) // important!
 
-		if !checkSignature(signature, timestamp, nonce, handler.setting.Token, bufferUnit.signatureBuf[:]) {
+		if !checkSignature(signature, timestamp, nonce, handler.setting.Token) {
 			handler.setting.InvalidRequestHandler(w, r, errors.New("check signature failed"))
 			return
 		}
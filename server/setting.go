@@ -0,0 +1,307 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/chanxuehong/wechat/cache"
+	"github.com/chanxuehong/wechat/message"
+	"github.com/chanxuehong/wechat/message/request"
+)
+
+// InvalidRequestHandlerFunc 处理不合法的请求, err 说明了不合法的原因.
+type InvalidRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+// UnknownRequestHandlerFunc 处理未知类型的消息(事件), body 是消息明文 xml 的副本
+// (兼容模式/安全模式下是解密之后的明文, 不是原始的密文请求体).
+type UnknownRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, body []byte)
+
+type TextRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, text *request.Text)
+type LinkRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, link *request.Link)
+type VoiceRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, voice *request.Voice)
+type LocationRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, location *request.Location)
+type ImageRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, image *request.Image)
+type VideoRequestHandlerFunc func(w http.ResponseWriter, r *http.Request, video *request.Video)
+
+type MenuClickEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.MenuClickEvent)
+type MenuViewEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.MenuViewEvent)
+type LocationEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.LocationEvent)
+type MerchantOrderEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.MerchantOrderEvent)
+type SubscribeEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.SubscribeEvent)
+type SubscribeByScanEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.SubscribeByScanEvent)
+type UnsubscribeEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.UnsubscribeEvent)
+type ScanEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.ScanEvent)
+type MassSendJobFinishEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.MassSendJobFinishEvent)
+
+type ScanCodePushEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.ScanCodePushEvent)
+type ScanCodeWaitMsgEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.ScanCodeWaitMsgEvent)
+type PicSysphotoEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.PicSysphotoEvent)
+type PicPhotoOrAlbumEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.PicPhotoOrAlbumEvent)
+type PicWeixinEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.PicWeixinEvent)
+type LocationSelectEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.LocationSelectEvent)
+type TemplateSendJobFinishEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.TemplateSendJobFinishEvent)
+type QualificationVerifySuccessEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.QualificationVerifySuccessEvent)
+type QualificationVerifyFailEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.QualificationVerifyFailEvent)
+type CardPassCheckEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.CardPassCheckEvent)
+type UserGetCardEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.UserGetCardEvent)
+type UserDelCardEventHandlerFunc func(w http.ResponseWriter, r *http.Request, event *request.UserDelCardEvent)
+
+// MessageHandlerFunc 是 Handler.SetMessageHandler 注册的统一消息处理函数.
+//  msg 是微信推送过来的消息(事件)的并集, ctx 可以用来读取该用户(按 OpenID)最近的历史消息,
+//  从而实现多轮对话; 返回值是要回复的内容, 返回 nil 表示不回复.
+type MessageHandlerFunc func(ctx *MessageContext, msg *request.MixMessage) *message.Reply
+
+// HandlerSetting 是 Handler 的配置, 包含了 Token 以及各种类型消息(事件)的处理函数.
+//  没有设置的处理函数在 initialize 时会被赋值为相应的默认实现.
+type HandlerSetting struct {
+	// Token 是公众号后台设置的 Token, 用于消息来源校验.
+	Token string
+
+	// AppId 是公众号的 AppId.
+	//  在兼容模式/安全模式下(EncodingAESKey 非空)用于校验解密后的消息; 设置了 AppSecret 后
+	//  也会被用来构造 Handler.AccessTokenServer() 返回的 core.AccessTokenServer.
+	AppId string
+
+	// AppSecret 是公众号的 AppSecret.
+	//  只有需要调用 Handler.AccessTokenServer() 时才需要设置.
+	AppSecret string
+
+	// EncodingAESKey 是公众号后台设置的消息加解密密钥(43 个字符).
+	//  非空时 Handler 会认为公众号运行在兼容模式/安全模式下, 自动解密微信推送过来的消息.
+	EncodingAESKey string
+
+	// Cache 是可选的共享缓存后端, 用来缓存 access_token, jsapi_ticket 等数据.
+	//  多个 Handler 实例(比如同一个公众号部署了多个 pod)可以共用同一个 Cache,
+	//  这样只有一个实例需要真正去刷新 access_token, 避免互相抢占配额.
+	//  Handler.AccessTokenServer() 会把这个 Cache 透传给它内部的 core.AccessTokenServer.
+	Cache cache.Cache
+
+	// MessageHandler 是通过 Handler.SetMessageHandler 设置的统一消息处理函数.
+	//  非 nil 时优先于下面各个按类型区分的处理函数生效.
+	MessageHandler MessageHandlerFunc
+
+	// MessageContext 是可选的多轮对话上下文, 传给 MessageHandler 使用.
+	MessageContext *MessageContext
+
+	InvalidRequestHandler InvalidRequestHandlerFunc
+	UnknownRequestHandler UnknownRequestHandlerFunc
+
+	TextRequestHandler     TextRequestHandlerFunc
+	LinkRequestHandler     LinkRequestHandlerFunc
+	VoiceRequestHandler    VoiceRequestHandlerFunc
+	LocationRequestHandler LocationRequestHandlerFunc
+	ImageRequestHandler    ImageRequestHandlerFunc
+	VideoRequestHandler    VideoRequestHandlerFunc
+
+	MenuClickEventHandler         MenuClickEventHandlerFunc
+	MenuViewEventHandler          MenuViewEventHandlerFunc
+	LocationEventHandler          LocationEventHandlerFunc
+	MerchantOrderEventHandler     MerchantOrderEventHandlerFunc
+	SubscribeEventHandler         SubscribeEventHandlerFunc
+	SubscribeByScanEventHandler   SubscribeByScanEventHandlerFunc
+	UnsubscribeEventHandler       UnsubscribeEventHandlerFunc
+	ScanEventHandler              ScanEventHandlerFunc
+	MassSendJobFinishEventHandler MassSendJobFinishEventHandlerFunc
+
+	ScanCodePushEventHandler               ScanCodePushEventHandlerFunc
+	ScanCodeWaitMsgEventHandler            ScanCodeWaitMsgEventHandlerFunc
+	PicSysphotoEventHandler                PicSysphotoEventHandlerFunc
+	PicPhotoOrAlbumEventHandler            PicPhotoOrAlbumEventHandlerFunc
+	PicWeixinEventHandler                  PicWeixinEventHandlerFunc
+	LocationSelectEventHandler             LocationSelectEventHandlerFunc
+	TemplateSendJobFinishEventHandler      TemplateSendJobFinishEventHandlerFunc
+	QualificationVerifySuccessEventHandler QualificationVerifySuccessEventHandlerFunc
+	QualificationVerifyFailEventHandler    QualificationVerifyFailEventHandlerFunc
+	CardPassCheckEventHandler              CardPassCheckEventHandlerFunc
+	UserGetCardEventHandler                UserGetCardEventHandlerFunc
+	UserDelCardEventHandler                UserDelCardEventHandlerFunc
+}
+
+func defaultInvalidRequestHandler(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, "Bad Request", http.StatusBadRequest)
+}
+
+func defaultUnknownRequestHandler(w http.ResponseWriter, r *http.Request, body []byte) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// 没有设置的按类型区分的处理函数一律默认成 no-op(只回一个 200), 避免用户只关心
+// 部分类型的消息(事件)时, 遇到没处理的类型就 nil func 调用 panic.
+func defaultTextRequestHandler(w http.ResponseWriter, r *http.Request, text *request.Text) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultLinkRequestHandler(w http.ResponseWriter, r *http.Request, link *request.Link) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultVoiceRequestHandler(w http.ResponseWriter, r *http.Request, voice *request.Voice) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultLocationRequestHandler(w http.ResponseWriter, r *http.Request, location *request.Location) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultImageRequestHandler(w http.ResponseWriter, r *http.Request, image *request.Image) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultVideoRequestHandler(w http.ResponseWriter, r *http.Request, video *request.Video) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func defaultMenuClickEventHandler(w http.ResponseWriter, r *http.Request, event *request.MenuClickEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultMenuViewEventHandler(w http.ResponseWriter, r *http.Request, event *request.MenuViewEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultLocationEventHandler(w http.ResponseWriter, r *http.Request, event *request.LocationEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultMerchantOrderEventHandler(w http.ResponseWriter, r *http.Request, event *request.MerchantOrderEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultSubscribeEventHandler(w http.ResponseWriter, r *http.Request, event *request.SubscribeEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultSubscribeByScanEventHandler(w http.ResponseWriter, r *http.Request, event *request.SubscribeByScanEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultUnsubscribeEventHandler(w http.ResponseWriter, r *http.Request, event *request.UnsubscribeEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultScanEventHandler(w http.ResponseWriter, r *http.Request, event *request.ScanEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultMassSendJobFinishEventHandler(w http.ResponseWriter, r *http.Request, event *request.MassSendJobFinishEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func defaultScanCodePushEventHandler(w http.ResponseWriter, r *http.Request, event *request.ScanCodePushEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultScanCodeWaitMsgEventHandler(w http.ResponseWriter, r *http.Request, event *request.ScanCodeWaitMsgEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultPicSysphotoEventHandler(w http.ResponseWriter, r *http.Request, event *request.PicSysphotoEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultPicPhotoOrAlbumEventHandler(w http.ResponseWriter, r *http.Request, event *request.PicPhotoOrAlbumEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultPicWeixinEventHandler(w http.ResponseWriter, r *http.Request, event *request.PicWeixinEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultLocationSelectEventHandler(w http.ResponseWriter, r *http.Request, event *request.LocationSelectEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultTemplateSendJobFinishEventHandler(w http.ResponseWriter, r *http.Request, event *request.TemplateSendJobFinishEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultQualificationVerifySuccessEventHandler(w http.ResponseWriter, r *http.Request, event *request.QualificationVerifySuccessEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultQualificationVerifyFailEventHandler(w http.ResponseWriter, r *http.Request, event *request.QualificationVerifyFailEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultCardPassCheckEventHandler(w http.ResponseWriter, r *http.Request, event *request.CardPassCheckEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultUserGetCardEventHandler(w http.ResponseWriter, r *http.Request, event *request.UserGetCardEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+func defaultUserDelCardEventHandler(w http.ResponseWriter, r *http.Request, event *request.UserDelCardEvent) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// initialize 把 setting 的内容拷贝到 handler.setting, 并为没有设置的字段填充默认值.
+func (setting *HandlerSetting) initialize(src *HandlerSetting) {
+	*setting = *src
+
+	if setting.InvalidRequestHandler == nil {
+		setting.InvalidRequestHandler = defaultInvalidRequestHandler
+	}
+	if setting.UnknownRequestHandler == nil {
+		setting.UnknownRequestHandler = defaultUnknownRequestHandler
+	}
+
+	if setting.TextRequestHandler == nil {
+		setting.TextRequestHandler = defaultTextRequestHandler
+	}
+	if setting.LinkRequestHandler == nil {
+		setting.LinkRequestHandler = defaultLinkRequestHandler
+	}
+	if setting.VoiceRequestHandler == nil {
+		setting.VoiceRequestHandler = defaultVoiceRequestHandler
+	}
+	if setting.LocationRequestHandler == nil {
+		setting.LocationRequestHandler = defaultLocationRequestHandler
+	}
+	if setting.ImageRequestHandler == nil {
+		setting.ImageRequestHandler = defaultImageRequestHandler
+	}
+	if setting.VideoRequestHandler == nil {
+		setting.VideoRequestHandler = defaultVideoRequestHandler
+	}
+
+	if setting.MenuClickEventHandler == nil {
+		setting.MenuClickEventHandler = defaultMenuClickEventHandler
+	}
+	if setting.MenuViewEventHandler == nil {
+		setting.MenuViewEventHandler = defaultMenuViewEventHandler
+	}
+	if setting.LocationEventHandler == nil {
+		setting.LocationEventHandler = defaultLocationEventHandler
+	}
+	if setting.MerchantOrderEventHandler == nil {
+		setting.MerchantOrderEventHandler = defaultMerchantOrderEventHandler
+	}
+	if setting.SubscribeEventHandler == nil {
+		setting.SubscribeEventHandler = defaultSubscribeEventHandler
+	}
+	if setting.SubscribeByScanEventHandler == nil {
+		setting.SubscribeByScanEventHandler = defaultSubscribeByScanEventHandler
+	}
+	if setting.UnsubscribeEventHandler == nil {
+		setting.UnsubscribeEventHandler = defaultUnsubscribeEventHandler
+	}
+	if setting.ScanEventHandler == nil {
+		setting.ScanEventHandler = defaultScanEventHandler
+	}
+	if setting.MassSendJobFinishEventHandler == nil {
+		setting.MassSendJobFinishEventHandler = defaultMassSendJobFinishEventHandler
+	}
+
+	if setting.ScanCodePushEventHandler == nil {
+		setting.ScanCodePushEventHandler = defaultScanCodePushEventHandler
+	}
+	if setting.ScanCodeWaitMsgEventHandler == nil {
+		setting.ScanCodeWaitMsgEventHandler = defaultScanCodeWaitMsgEventHandler
+	}
+	if setting.PicSysphotoEventHandler == nil {
+		setting.PicSysphotoEventHandler = defaultPicSysphotoEventHandler
+	}
+	if setting.PicPhotoOrAlbumEventHandler == nil {
+		setting.PicPhotoOrAlbumEventHandler = defaultPicPhotoOrAlbumEventHandler
+	}
+	if setting.PicWeixinEventHandler == nil {
+		setting.PicWeixinEventHandler = defaultPicWeixinEventHandler
+	}
+	if setting.LocationSelectEventHandler == nil {
+		setting.LocationSelectEventHandler = defaultLocationSelectEventHandler
+	}
+	if setting.TemplateSendJobFinishEventHandler == nil {
+		setting.TemplateSendJobFinishEventHandler = defaultTemplateSendJobFinishEventHandler
+	}
+	if setting.QualificationVerifySuccessEventHandler == nil {
+		setting.QualificationVerifySuccessEventHandler = defaultQualificationVerifySuccessEventHandler
+	}
+	if setting.QualificationVerifyFailEventHandler == nil {
+		setting.QualificationVerifyFailEventHandler = defaultQualificationVerifyFailEventHandler
+	}
+	if setting.CardPassCheckEventHandler == nil {
+		setting.CardPassCheckEventHandler = defaultCardPassCheckEventHandler
+	}
+	if setting.UserGetCardEventHandler == nil {
+		setting.UserGetCardEventHandler = defaultUserGetCardEventHandler
+	}
+	if setting.UserDelCardEventHandler == nil {
+		setting.UserDelCardEventHandler = defaultUserDelCardEventHandler
+	}
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"crypto/sha1"
+	"io"
+	"sort"
+)
+
+// checkSignature 校验 signature 是否等于 sha1(sort(token, timestamp, nonce)).
+//  token+timestamp+nonce 的长度没有上限(没有任何协议约束 nonce 的长度), 所以不能用
+//  固定大小的缓冲区拼接后再 Sum, 而是用 sha1.New() 流式写入, 写入量多大都不会越界.
+func checkSignature(signature, timestamp, nonce, token string) bool {
+	strs := []string{token, timestamp, nonce}
+	sort.Strings(strs)
+
+	h := sha1.New()
+	for _, s := range strs {
+		io.WriteString(h, s)
+	}
+	return hexEncode(h.Sum(nil)) == signature
+}